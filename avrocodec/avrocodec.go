@@ -0,0 +1,34 @@
+// Package avrocodec provides a retry.Codec backed by a github.com/hamba/avro
+// schema, for teams that need to share a binary wire format with other
+// producers and consumers on the same queue rather than the default JSON
+// encoding. It's a separate package so that consumers who only need the
+// default codec don't have to pull in the avro dependency.
+package avrocodec
+
+import (
+	"github.com/hamba/avro/v2"
+	"github.com/pkg/errors"
+)
+
+// Codec is a retry.Codec backed by a github.com/hamba/avro schema.
+type Codec struct {
+	schema avro.Schema
+}
+
+// New parses schemaJSON once so Marshal and Unmarshal can reuse the
+// compiled schema on every call. Pass the result as retry.Config.Codec.
+func New(schemaJSON string) (*Codec, error) {
+	schema, err := avro.Parse(schemaJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Avro schema")
+	}
+	return &Codec{schema: schema}, nil
+}
+
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	return avro.Marshal(c.schema, v)
+}
+
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	return avro.Unmarshal(c.schema, data, v)
+}
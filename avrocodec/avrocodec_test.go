@@ -0,0 +1,39 @@
+package avrocodec
+
+import "testing"
+
+const testSchema = `{
+	"type": "record",
+	"name": "testRecord",
+	"fields": [
+		{"name": "name", "type": "string"},
+		{"name": "age", "type": "int"}
+	]
+}`
+
+type testRecord struct {
+	Name string `avro:"name"`
+	Age  int32  `avro:"age"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	codec, err := New(testSchema)
+	if err != nil {
+		t.Fatalf("unexpected error parsing schema: %v", err)
+	}
+
+	want := testRecord{Name: "ada", Age: 36}
+	data, err := codec.Marshal(&want)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling: %v", err)
+	}
+
+	var got testRecord
+	if err := codec.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unexpected error unmarshaling: %v", err)
+	}
+
+	if got != want {
+		t.Errorf("expected record to round-trip, got=%+v want=%+v", got, want)
+	}
+}
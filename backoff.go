@@ -1,6 +1,10 @@
 package retry
 
-import "time"
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
 
 // BackoffFunc is a function that maps the retry attempt
 // to a delay (in seconds)
@@ -53,3 +57,81 @@ func ConstantBackoff(seedDelay time.Duration) BackoffFunc {
 		return seedDelay
 	}
 }
+
+// FullJitterBackoff backs off exponentially like ExponentialBackoff, but
+// picks a uniformly random delay between 0 and that exponential ceiling on
+// each attempt, per the "full jitter" algorithm in
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+// This spreads retries out so that many workers backing off from the same
+// failure don't all retry in lockstep.
+//
+// For a base of 1 second and a cap of 1 minute:
+// Attempt 0 - delay 0 seconds
+// Attempt 1 - a random delay in [0s, 1s)
+// Attempt 2 - a random delay in [0s, 2s)
+// Attempt 9 - a random delay in [0s, 1m) (the exponential ceiling is capped)
+func FullJitterBackoff(base, cap time.Duration) BackoffFunc {
+	return fullJitterBackoff(base, cap, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// fullJitterBackoff is FullJitterBackoff with the random source injected,
+// so tests can seed it for deterministic sequences.
+func fullJitterBackoff(base, cap time.Duration, rng *rand.Rand) BackoffFunc {
+	var mu sync.Mutex
+	return func(attempt uint) time.Duration {
+		if attempt == 0 {
+			return 0
+		}
+		ceiling := base << (attempt - 1)
+		if ceiling <= 0 || ceiling > cap {
+			ceiling = cap
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Duration(rng.Int63n(int64(ceiling)))
+	}
+}
+
+// DecorrelatedJitterBackoff also spreads retries out, but derives each
+// delay from the previous one rather than purely from the attempt number,
+// which in practice spreads retries out more evenly than
+// FullJitterBackoff. It implements the "decorrelated jitter" algorithm
+// from the same article linked from FullJitterBackoff.
+//
+// Each call to DecorrelatedJitterBackoff returns an independent
+// BackoffFunc with its own "previous delay" state, starting at base; that
+// state is shared across every attempt made through the returned
+// BackoffFunc, so don't call DecorrelatedJitterBackoff more than once for
+// the same logical series of retries. The returned BackoffFunc is
+// goroutine-safe.
+func DecorrelatedJitterBackoff(base, cap time.Duration) BackoffFunc {
+	return decorrelatedJitterBackoff(base, cap, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// decorrelatedJitterBackoff is DecorrelatedJitterBackoff with the random
+// source injected, so tests can seed it for deterministic sequences.
+func decorrelatedJitterBackoff(base, cap time.Duration, rng *rand.Rand) BackoffFunc {
+	var (
+		mu   sync.Mutex
+		prev = base
+	)
+	return func(attempt uint) time.Duration {
+		if attempt == 0 {
+			return 0
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		spread := prev*3 - base
+		if spread <= 0 {
+			spread = 1
+		}
+		next := base + time.Duration(rng.Int63n(int64(spread)))
+		if next > cap {
+			next = cap
+		}
+		prev = next
+		return next
+	}
+}
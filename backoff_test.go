@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"math/rand"
 	"testing"
 	"time"
 )
@@ -157,3 +158,53 @@ func TestConstant(t *testing.T) {
 		}
 	}
 }
+
+func TestFullJitter(t *testing.T) {
+	expect := []time.Duration{
+		0,
+		947779410,
+		1082153551,
+		3666145821,
+		2235010051,
+		287113937,
+	}
+
+	backoff := fullJitterBackoff(time.Second, time.Minute, rand.New(rand.NewSource(1)))
+	for attempt, want := range expect {
+		if got := backoff(uint(attempt)); got != want {
+			t.Errorf("attempt %d: received incorrect backoff delay got=%s want=%s", attempt, got, want)
+		}
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	expect := []time.Duration{
+		0,
+		2947779410,
+		6160564301,
+		5481081350,
+		14298767651,
+		42109992117,
+	}
+
+	backoff := decorrelatedJitterBackoff(time.Second, time.Minute, rand.New(rand.NewSource(1)))
+	for attempt, want := range expect {
+		if got := backoff(uint(attempt)); got != want {
+			t.Errorf("attempt %d: received incorrect backoff delay got=%s want=%s", attempt, got, want)
+		}
+	}
+}
+
+// TestFullJitterIndependentGenerators verifies that each call to
+// fullJitterBackoff starts its own generator rather than sharing state, so
+// two series of retries seeded the same way produce identical sequences.
+func TestFullJitterIndependentGenerators(t *testing.T) {
+	a := fullJitterBackoff(time.Second, time.Minute, rand.New(rand.NewSource(42)))
+	b := fullJitterBackoff(time.Second, time.Minute, rand.New(rand.NewSource(42)))
+
+	for attempt := uint(1); attempt <= 5; attempt++ {
+		if got, want := a(attempt), b(attempt); got != want {
+			t.Errorf("attempt %d: independent generators diverged got=%s want=%s", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package retry
+
+import "context"
+
+// Client is the subset of SQS operations the poll loop needs, expressed
+// independently of any particular AWS SDK so that New (aws-sdk-go v1, via
+// NewV1Client) and retry/sqsv2.New (aws-sdk-go-v2) can share every other
+// line of this package. NewWithClient accepts any implementation,
+// including mockSQS in tests.
+type Client interface {
+	ReceiveMessage(ctx context.Context, in ReceiveMessageInput) (ReceiveMessageOutput, error)
+	SendMessage(ctx context.Context, in SendMessageInput) error
+	SendMessageBatch(ctx context.Context, in SendMessageBatchInput) ([]BatchResultError, error)
+	DeleteMessageBatch(ctx context.Context, in DeleteMessageBatchInput) ([]BatchResultError, error)
+	ChangeMessageVisibilityBatch(ctx context.Context, in ChangeMessageVisibilityBatchInput) ([]BatchResultError, error)
+}
+
+// RawMessage is a message as received from the queue, reduced to the
+// fields the poll loop needs, independent of SDK version.
+type RawMessage struct {
+	Body              string
+	MessageID         string
+	ReceiptHandle     string
+	MessageAttributes map[string]string
+}
+
+// ReceiveMessageInput requests up to MaxNumberOfMessages messages, long
+// polling for WaitTimeSeconds.
+type ReceiveMessageInput struct {
+	QueueURL            string
+	MaxNumberOfMessages int64
+	WaitTimeSeconds     int64
+}
+
+// ReceiveMessageOutput is empty (not missing) when the long poll times out
+// without anything to deliver.
+type ReceiveMessageOutput struct {
+	Messages []RawMessage
+}
+
+// SendMessageInput sends a single new message, delayed by DelaySeconds.
+type SendMessageInput struct {
+	QueueURL     string
+	Body         string
+	DelaySeconds int64
+}
+
+// SendMessageBatchEntry is one message within a SendMessageBatchInput. ID
+// only needs to be unique within the batch; it's matched back up against
+// BatchResultError.ID on failure.
+type SendMessageBatchEntry struct {
+	ID           string
+	Body         string
+	DelaySeconds int64
+}
+
+type SendMessageBatchInput struct {
+	QueueURL string
+	Entries  []SendMessageBatchEntry
+}
+
+// DeleteMessageBatchEntry is one message within a DeleteMessageBatchInput.
+// ID only needs to be unique within the batch.
+type DeleteMessageBatchEntry struct {
+	ID            string
+	ReceiptHandle string
+}
+
+type DeleteMessageBatchInput struct {
+	QueueURL string
+	Entries  []DeleteMessageBatchEntry
+}
+
+// ChangeMessageVisibilityBatchEntry is one message within a
+// ChangeMessageVisibilityBatchInput. ID only needs to be unique within the
+// batch.
+type ChangeMessageVisibilityBatchEntry struct {
+	ID                string
+	ReceiptHandle     string
+	VisibilityTimeout int64
+}
+
+type ChangeMessageVisibilityBatchInput struct {
+	QueueURL string
+	Entries  []ChangeMessageVisibilityBatchEntry
+}
+
+// BatchResultError reports one entry's failure out of a batch call, keyed
+// by the ID it was submitted with.
+type BatchResultError struct {
+	ID      string
+	Message string
+}
@@ -0,0 +1,137 @@
+package retry
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// NewV1Client adapts an aws-sdk-go (v1) SQS client for use as a Client.
+// New and NewWithContext build one of these internally; call it directly
+// if you need to customize the underlying session (custom credentials, a
+// non-default endpoint, request retries, etc.) and hand the result to
+// NewWithClient.
+func NewV1Client(api sqsiface.SQSAPI) Client {
+	return v1Client{api: api}
+}
+
+type v1Client struct {
+	api sqsiface.SQSAPI
+}
+
+func (c v1Client) ReceiveMessage(ctx context.Context, in ReceiveMessageInput) (ReceiveMessageOutput, error) {
+	output, err := c.api.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(in.QueueURL),
+		MaxNumberOfMessages:   aws.Int64(in.MaxNumberOfMessages),
+		WaitTimeSeconds:       aws.Int64(in.WaitTimeSeconds),
+		MessageAttributeNames: aws.StringSlice([]string{"All"}),
+	})
+	if err != nil {
+		return ReceiveMessageOutput{}, err
+	}
+
+	messages := make([]RawMessage, len(output.Messages))
+	for i, m := range output.Messages {
+		messages[i] = RawMessage{
+			Body:              aws.StringValue(m.Body),
+			MessageID:         aws.StringValue(m.MessageId),
+			ReceiptHandle:     aws.StringValue(m.ReceiptHandle),
+			MessageAttributes: messageAttributeValuesToMap(m.MessageAttributes),
+		}
+	}
+	return ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (c v1Client) SendMessage(ctx context.Context, in SendMessageInput) error {
+	_, err := c.api.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:     aws.String(in.QueueURL),
+		MessageBody:  aws.String(in.Body),
+		DelaySeconds: aws.Int64(in.DelaySeconds),
+	})
+	return err
+}
+
+func (c v1Client) SendMessageBatch(ctx context.Context, in SendMessageBatchInput) ([]BatchResultError, error) {
+	entries := make([]*sqs.SendMessageBatchRequestEntry, len(in.Entries))
+	for i, e := range in.Entries {
+		entries[i] = &sqs.SendMessageBatchRequestEntry{
+			Id:           aws.String(e.ID),
+			MessageBody:  aws.String(e.Body),
+			DelaySeconds: aws.Int64(e.DelaySeconds),
+		}
+	}
+	output, err := c.api.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(in.QueueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batchFailuresToErrors(output.Failed), nil
+}
+
+func (c v1Client) DeleteMessageBatch(ctx context.Context, in DeleteMessageBatchInput) ([]BatchResultError, error) {
+	entries := make([]*sqs.DeleteMessageBatchRequestEntry, len(in.Entries))
+	for i, e := range in.Entries {
+		entries[i] = &sqs.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(e.ID),
+			ReceiptHandle: aws.String(e.ReceiptHandle),
+		}
+	}
+	output, err := c.api.DeleteMessageBatchWithContext(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(in.QueueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batchFailuresToErrors(output.Failed), nil
+}
+
+func (c v1Client) ChangeMessageVisibilityBatch(ctx context.Context, in ChangeMessageVisibilityBatchInput) ([]BatchResultError, error) {
+	entries := make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, len(in.Entries))
+	for i, e := range in.Entries {
+		entries[i] = &sqs.ChangeMessageVisibilityBatchRequestEntry{
+			Id:                aws.String(e.ID),
+			ReceiptHandle:     aws.String(e.ReceiptHandle),
+			VisibilityTimeout: aws.Int64(e.VisibilityTimeout),
+		}
+	}
+	output, err := c.api.ChangeMessageVisibilityBatchWithContext(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+		QueueUrl: aws.String(in.QueueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batchFailuresToErrors(output.Failed), nil
+}
+
+// messageAttributeValuesToMap reduces SQS's attribute value wrapper down to
+// a plain map of string values, since that covers the overwhelming common
+// case and is what Handler needs to make a decision.
+func messageAttributeValuesToMap(attrs map[string]*sqs.MessageAttributeValue) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		out[k] = aws.StringValue(v.StringValue)
+	}
+	return out
+}
+
+// batchFailuresToErrors converts the shared []*sqs.BatchResultErrorEntry
+// shape returned by every batch API's Failed field.
+func batchFailuresToErrors(failed []*sqs.BatchResultErrorEntry) []BatchResultError {
+	if len(failed) == 0 {
+		return nil
+	}
+	out := make([]BatchResultError, len(failed))
+	for i, f := range failed {
+		out[i] = BatchResultError{ID: aws.StringValue(f.Id), Message: aws.StringValue(f.Message)}
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package retry
+
+import "encoding/json"
+
+// Codec controls how a message (retry envelope plus user Payload) is
+// serialized to and from the SQS message body. Producers and consumers
+// sharing a queue can swap in a Codec to use a schema other than this
+// package's default JSON encoding.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// jsonCodec is the default Codec, used when Config.Codec is unset.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// codec returns the configured Codec, defaulting to JSON.
+func (c Config) codec() Codec {
+	if c.Codec == nil {
+		return jsonCodec{}
+	}
+	return c.Codec
+}
@@ -0,0 +1,32 @@
+package retry
+
+import "testing"
+
+func TestConfigCodecDefaultsToJSON(t *testing.T) {
+	var config Config
+	codec := config.codec()
+	if _, ok := codec.(jsonCodec); !ok {
+		t.Errorf("expected default codec to be jsonCodec, got %T", codec)
+	}
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Marshal(v interface{}) ([]byte, error) {
+	s := v.(*string)
+	return []byte(*s), nil
+}
+
+func (stringCodec) Unmarshal(data []byte, v interface{}) error {
+	s := v.(*string)
+	*s = string(data)
+	return nil
+}
+
+func TestConfigCodecUsesConfigured(t *testing.T) {
+	config := Config{Codec: stringCodec{}}
+	codec := config.codec()
+	if _, ok := codec.(stringCodec); !ok {
+		t.Errorf("expected configured codec to be used, got %T", codec)
+	}
+}
@@ -1,73 +1,120 @@
 package retry
 
 import (
+	"context"
+	"strconv"
+	"sync"
 	"time"
-
-	"github.com/aws/aws-sdk-go/service/sqs"
-	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 )
 
 type mockFunc struct {
 	SucceedOnAttemptNumber int
-	InvokedCount           int
+
+	mu           sync.Mutex
+	InvokedCount int
 }
 
 func (m *mockFunc) invoke(Message) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.InvokedCount++
 	return m.InvokedCount >= m.SucceedOnAttemptNumber
 }
 
+// mockSQS is a Client, so it exercises the exact same retry.go code paths
+// regardless of which real SDK (v1 or v2) a Client implementation wraps.
 type mockSQS struct {
-	sqsiface.SQSAPI
-	storage                    map[*string]*string
-	clock                      *mockClock
-	ReceiveMessageInvokedCount int
-	// ReceiveMessageFunc         func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error)
-	SendMessageInvokedCount int
-	// SendMessageFunc            func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error)
-	DeleteMessageInvokedCount int
-	// DeleteMessageFunc          func(*sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error)
+	mu                                    sync.Mutex
+	storage                               map[string]string
+	clock                                 *mockClock
+	ReceiveMessageInvokedCount            int
+	SendMessageInvokedCount               int
+	DeleteMessageInvokedCount             int
+	SendMessageBatchCallCount             int
+	DeleteMessageBatchCallCount           int
+	ChangeMessageVisibilityInvokedCount   int
+	ChangeMessageVisibilityBatchCallCount int
 }
 
 func NewMockSQS(clock *mockClock) *mockSQS {
 	return &mockSQS{
-		storage: make(map[*string]*string),
+		storage: make(map[string]string),
 		clock:   clock,
 	}
 }
 
-func (m *mockSQS) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+func (m *mockSQS) ReceiveMessage(_ context.Context, in ReceiveMessageInput) (ReceiveMessageOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.ReceiveMessageInvokedCount++
-	for key, value := range m.storage {
-		return &sqs.ReceiveMessageOutput{
-			Messages: []*sqs.Message{
-				&sqs.Message{
-					Body:          value,
-					ReceiptHandle: key,
-				},
-			},
-		}, nil
+
+	max := 1
+	if in.MaxNumberOfMessages > 0 {
+		max = int(in.MaxNumberOfMessages)
 	}
 
-	return &sqs.ReceiveMessageOutput{}, nil
+	var messages []RawMessage
+	for key, body := range m.storage {
+		messages = append(messages, RawMessage{
+			Body:          body,
+			ReceiptHandle: key,
+			// The mock never rotates a stored message's key, so it also
+			// makes a perfectly stable stand-in for SQS's MessageId.
+			MessageID: key,
+		})
+		if len(messages) >= max {
+			break
+		}
+	}
+
+	return ReceiveMessageOutput{Messages: messages}, nil
 }
 
-func (m *mockSQS) SendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+func (m *mockSQS) SendMessage(_ context.Context, in SendMessageInput) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.SendMessageInvokedCount++
-	str := string(m.SendMessageInvokedCount)
-	m.storage[&str] = in.MessageBody
+	key := strconv.Itoa(m.SendMessageInvokedCount)
+	m.storage[key] = in.Body
+	m.clock.time = m.clock.time.Add(time.Duration(in.DelaySeconds) * time.Second)
+	return nil
+}
 
-	if in.DelaySeconds != nil {
-		m.clock.time = m.clock.time.Add(time.Duration(*in.DelaySeconds) * time.Second)
+func (m *mockSQS) SendMessageBatch(_ context.Context, in SendMessageBatchInput) ([]BatchResultError, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.SendMessageBatchCallCount++
+
+	for _, entry := range in.Entries {
+		m.SendMessageInvokedCount++
+		key := strconv.Itoa(m.SendMessageInvokedCount)
+		m.storage[key] = entry.Body
+		m.clock.time = m.clock.time.Add(time.Duration(entry.DelaySeconds) * time.Second)
 	}
+	return nil, nil
+}
+
+func (m *mockSQS) DeleteMessageBatch(_ context.Context, in DeleteMessageBatchInput) ([]BatchResultError, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.DeleteMessageBatchCallCount++
 
-	return &sqs.SendMessageOutput{}, nil
+	for _, entry := range in.Entries {
+		m.DeleteMessageInvokedCount++
+		delete(m.storage, entry.ReceiptHandle)
+	}
+	return nil, nil
 }
 
-func (m *mockSQS) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
-	m.DeleteMessageInvokedCount++
-	delete(m.storage, in.ReceiptHandle)
-	return &sqs.DeleteMessageOutput{}, nil
+func (m *mockSQS) ChangeMessageVisibilityBatch(_ context.Context, in ChangeMessageVisibilityBatchInput) ([]BatchResultError, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ChangeMessageVisibilityBatchCallCount++
+
+	for range in.Entries {
+		m.ChangeMessageVisibilityInvokedCount++
+	}
+	return nil, nil
 }
 
 type mockClock struct {
@@ -1,14 +1,16 @@
 package retry
 
 import (
-	"encoding/json"
+	"context"
+	"runtime/debug"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
-	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/pkg/errors"
 )
 
@@ -16,6 +18,44 @@ import (
 // can be delayed in the queue. SQS currently allows up to 900
 const MaxQueueDelaySeconds = 900
 
+// maxBatchSize is the most messages SQS will let us receive, delete, or
+// send in a single batch request.
+const maxBatchSize = 10
+
+// maxVisibilityTimeoutSeconds is the longest visibility timeout SQS allows
+// in a single ChangeMessageVisibility call, and the most a message's total
+// visibility timeout can be extended to from the time it was received.
+const maxVisibilityTimeoutSeconds = 43200
+
+// RetryMode controls how a message is retried once Handler reports that
+// it isn't done yet.
+type RetryMode int
+
+const (
+	// SendAndDeleteMode, the default, sends a brand new message carrying
+	// the updated attempt count/backoff and deletes the original. Simple,
+	// but every retry costs a SendMessage and a DeleteMessage, and a
+	// backoff longer than MaxQueueDelaySeconds means the same message is
+	// re-sent over and over until the clock catches up.
+	SendAndDeleteMode RetryMode = iota
+	// VisibilityTimeoutMode leaves the original message in the queue and
+	// instead pushes its visibility timeout out to the next attempt
+	// (chunked at maxVisibilityTimeoutSeconds), so one logical job stays
+	// a single SQS message across every attempt.
+	//
+	// AttemptedCount/NextAttempt progress is tracked only in the memory of
+	// the single Retrier that first received the message, not in SQS
+	// itself, because ChangeMessageVisibility can't rewrite a message's
+	// body the way a resend can. This means VisibilityTimeoutMode is only
+	// safe with exactly one long-lived consumer process per queue: running
+	// more than one Retrier against the same queue, or restarting one,
+	// loses that progress, and a redelivered message falls back to the
+	// AttemptedCount/NextAttempt encoded on its original send, so MaxAttempts
+	// and backoff silently stop being honored.
+	// Use SendAndDeleteMode if you need multiple consumers or restarts.
+	VisibilityTimeoutMode
+)
+
 // Config defines parameters used in the polling process
 type Config struct {
 	QueueURL string
@@ -29,9 +69,85 @@ type Config struct {
 	BackoffStrategy BackoffFunc
 	ErrorHandler    ErrorHandler
 	Handler         ActionHandler
+
+	// MaxConcurrency is the number of messages that are processed by
+	// Handler at the same time out of a single received batch. Defaults
+	// to 1 (no concurrency). Any value greater than 1 means Handler and
+	// ErrorHandler must be safe to call concurrently from multiple
+	// goroutines, since they're no longer invoked serially from a single
+	// poll-loop goroutine.
+	MaxConcurrency int
+	// BatchSize is how many messages are requested per long poll (mapped
+	// to MaxNumberOfMessages) and, in turn, the most that are ever
+	// coalesced into a single DeleteMessageBatch/SendMessageBatch call.
+	// Valid values are 1-10; defaults to 1.
+	BatchSize int
+
+	// RetryMode selects how a not-yet-complete message is retried.
+	// Defaults to SendAndDeleteMode.
+	RetryMode RetryMode
+
+	// Codec controls how the message envelope is serialized to and from
+	// the SQS message body. Defaults to JSON.
+	Codec Codec
+}
+
+// concurrency returns the configured MaxConcurrency, defaulting to 1.
+func (c Config) concurrency() int {
+	if c.MaxConcurrency <= 0 {
+		return 1
+	}
+	return c.MaxConcurrency
 }
 
-type ActionHandler func(Message) (complete bool)
+// receiveBatchSize returns the configured BatchSize, clamped to the 1-10
+// range SQS accepts for MaxNumberOfMessages.
+func (c Config) receiveBatchSize() int64 {
+	switch {
+	case c.BatchSize <= 0:
+		return 1
+	case c.BatchSize > maxBatchSize:
+		return maxBatchSize
+	default:
+		return int64(c.BatchSize)
+	}
+}
+
+// ActionHandler processes a single message. A nil error means the message
+// is done and should be deleted. ErrRetry (or any other non-nil error)
+// retries the message with normal backoff. ErrAbort neither deletes nor
+// requeues the message: it stays in the queue, SQS redelivers it once its
+// visibility timeout expires, and it reaches a DLQ only through the queue's
+// own redrive policy. Handler can be invoked again on those redeliveries.
+// A job whose first attempt, made by Job, returns ErrAbort is never
+// enqueued. A panic inside Handler is recovered and treated the
+// same as returning ErrRetry, with the panic reported to ErrorHandler.
+type ActionHandler func(ctx context.Context, msg Message) error
+
+// ErrRetry tells the poll loop to retry a message with normal backoff.
+var ErrRetry = errors.New("retry: retry this message with normal backoff")
+
+// ErrAbort tells the poll loop to leave a message in the queue without
+// deleting or requeuing it, for the queue's redrive policy to move to a
+// DLQ. It does not stop later redeliveries of the message from reaching
+// Handler.
+var ErrAbort = errors.New("retry: abort and stop retrying this message")
+
+// LegacyActionHandler is the handler signature ActionHandler used before it
+// gained a context and the ability to signal abort via ErrAbort. Adapt one
+// with AdaptLegacyHandler.
+type LegacyActionHandler func(Message) (complete bool)
+
+// AdaptLegacyHandler wraps a LegacyActionHandler so it can be used as
+// Config.Handler: true is treated as success, false as ErrRetry.
+func AdaptLegacyHandler(handler LegacyActionHandler) ActionHandler {
+	return func(_ context.Context, msg Message) error {
+		if handler(msg) {
+			return nil
+		}
+		return ErrRetry
+	}
+}
 
 type ErrorHandler func(error)
 
@@ -45,25 +161,102 @@ func (realClock) Now() time.Time {
 	return time.Now()
 }
 
-type message struct {
-	Message
-	ReceivedTime time.Time `json:"received_time"`
-	NextAttempt  time.Time `json:"next_attempt"`
+type Message struct {
+	ID             int       `json:"id"`
+	AttemptedCount uint      `json:"attempted_count"`
+	Payload        []byte    `json:"payload,omitempty"`
+	ReceivedTime   time.Time `json:"received_time"`
+	NextAttempt    time.Time `json:"next_attempt"`
+
+	// ReceiptHandle and MessageAttributes are populated from the SQS
+	// message when Handler is invoked from the poll loop. They aren't part
+	// of the envelope persisted to the queue body: ReceiptHandle changes
+	// on every redelivery, and MessageAttributes comes from SQS itself
+	// rather than from us.
+	ReceiptHandle     string            `json:"-"`
+	MessageAttributes map[string]string `json:"-"`
 }
 
-type Message struct {
-	ID             int  `json:"id"`
-	AttemptedCount uint `json:"attempted_count"`
+// visibilityStore holds the AttemptedCount/NextAttempt progress for
+// messages being retried under VisibilityTimeoutMode, keyed by SQS
+// MessageId. It exists because ChangeMessageVisibility can't rewrite a
+// message's body the way a resend can, yet a message's ReceiptHandle
+// changes every time it's redelivered, so progress has to be tracked
+// somewhere that survives across receives. A nil *visibilityStore is
+// valid and behaves as an always-empty store, so Retriers built without
+// one of the New* constructors still work for SendAndDeleteMode.
+type visibilityStore struct {
+	mu    sync.Mutex
+	state map[string]Message
+}
+
+func newVisibilityStore() *visibilityStore {
+	return &visibilityStore{state: make(map[string]Message)}
+}
+
+func (s *visibilityStore) load(id string, fallback Message) Message {
+	if s == nil {
+		return fallback
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.state[id]; ok {
+		return m
+	}
+	return fallback
+}
+
+func (s *visibilityStore) save(id string, m Message) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state[id] = m
+}
+
+func (s *visibilityStore) delete(id string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.state, id)
 }
 
 type Retrier struct {
 	time   clock
 	config Config
-	sqs    sqsiface.SQSAPI
+	sqs    Client
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+	visibility *visibilityStore
+}
+
+// New begins polling based on the provided Config, using aws-sdk-go (v1).
+// An error is returned if the AWS session can't be constructed, e.g. a
+// malformed shared config/credentials file. Call Stop to shut the poll loop
+// down; use NewWithContext instead if you'd rather tie it to a context you
+// already have. Use NewWithClient if you need a different SDK version (see
+// retry/sqsv2) or a custom-built v1 client (see NewV1Client).
+func New(config Config) (Retrier, error) {
+	r, err := newRetrier(context.Background(), config)
+	if err != nil {
+		return Retrier{}, err
+	}
+	return *r, nil
 }
 
-// New begins polling based on the provided Config
-func New(config Config) Retrier {
+// NewWithContext begins polling based on the provided Config, using
+// aws-sdk-go (v1), and ties the poll loop's lifetime to ctx. Call Stop (or
+// cancel ctx) to shut it down.
+func NewWithContext(ctx context.Context, config Config) (*Retrier, error) {
+	return newRetrier(ctx, config)
+}
+
+func newRetrier(ctx context.Context, config Config) (*Retrier, error) {
 	var creds *credentials.Credentials
 	if config.AWSAccessKeyID != "" || config.AWSSecret != "" {
 		creds = credentials.NewStaticCredentials(config.AWSAccessKeyID, config.AWSSecret, "")
@@ -73,61 +266,156 @@ func New(config Config) Retrier {
 		region = aws.String(config.AWSRegion)
 	}
 
-	b := Retrier{
-		time:   realClock{},
-		config: config,
-		sqs: sqs.New(session.New(&aws.Config{
-			Region:      region,
-			Credentials: creds,
-		})),
+	sess, err := session.NewSession(&aws.Config{
+		Region:      region,
+		Credentials: creds,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create AWS session")
+	}
+
+	return NewWithClient(ctx, NewV1Client(sqs.New(sess)), config)
+}
+
+// NewWithClient begins polling based on the provided Config against an
+// already-constructed Client, and ties the poll loop's lifetime to ctx.
+// Call Stop (or cancel ctx) to shut it down. This is the entry point for
+// retry/sqsv2 (aws-sdk-go-v2) and for a v1 client built with custom
+// session options via NewV1Client.
+func NewWithClient(ctx context.Context, client Client, config Config) (*Retrier, error) {
+	pollCtx, cancel := context.WithCancel(ctx)
+	r := &Retrier{
+		time:       realClock{},
+		config:     config,
+		sqs:        client,
+		ctx:        pollCtx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		visibility: newVisibilityStore(),
+	}
+	go r.poll(pollCtx)
+	return r, nil
+}
+
+// Stop cancels the poll loop and blocks until any in-flight pollOnce
+// (including a pending long poll and its worker pool) has returned. It is
+// safe to call multiple times, and works the same whether the Retrier was
+// built with New, NewWithContext, or NewWithClient. Only a Retrier that
+// was never built through a constructor (a bare Retrier{} struct literal,
+// as used in this package's own tests) has no poll loop to stop, in which
+// case Stop is a no-op.
+func (r Retrier) Stop() error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	if r.done != nil {
+		<-r.done
+	}
+	return nil
+}
+
+// context returns the context this Retrier should use for outgoing SQS
+// calls made outside of the poll loop (e.g. from Job), falling back to
+// context.Background() for Retriers built without one of the New*
+// constructors.
+func (r Retrier) context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
 	}
-	go b.poll()
-	return b
+	return context.Background()
 }
 
 func (r Retrier) Job(id int) error {
-	message := message{
-		Message: Message{
-			ID:             id,
-			AttemptedCount: 0,
-		},
-		ReceivedTime: r.time.Now(),
-		NextAttempt:  r.time.Now().Add(r.config.BackoffStrategy(0)),
+	message := Message{
+		ID:             id,
+		AttemptedCount: 0,
+		ReceivedTime:   r.time.Now(),
+		NextAttempt:    r.time.Now().Add(r.config.BackoffStrategy(0)),
 	}
 
-	return r.workMessage(message)
+	result, outcome := r.workMessage(r.context(), message)
+	if outcome != handlerRetry {
+		return nil
+	}
+	return r.sendToQueue(r.context(), result)
 }
 
-// workMessage handles a message after we've taken it out of SQS
-// (or we create it manually if it's a new job)
-func (r Retrier) workMessage(message message) error {
+// handlerOutcome categorizes what Handler decided about a message.
+type handlerOutcome int
+
+const (
+	handlerRetry handlerOutcome = iota
+	handlerDone
+	handlerAbort
+)
+
+// workMessage decides what should happen to a message that's being
+// processed, either because it's a brand new job (Job) or because it came
+// off the queue during a poll. It does not perform any SQS I/O itself;
+// callers are responsible for requeuing the returned message when the
+// outcome is handlerRetry, so that retries coming out of the same poll can
+// be coalesced into a single batch request.
+func (r Retrier) workMessage(ctx context.Context, message Message) (result Message, outcome handlerOutcome) {
 	// Compute visiblity timeout and update message to account for backoff
 	message, skip := r.computeMessageDelay(message)
-	if !skip {
-		// Perform the action requested for this item
-		complete := r.config.Handler(message.Message)
-		if complete {
-			return nil
+	if skip {
+		return message, handlerRetry
+	}
+
+	switch err := r.invokeHandler(ctx, message); err {
+	case nil:
+		return message, handlerDone
+	case ErrAbort:
+		return message, handlerAbort
+	default:
+		return message, handlerRetry
+	}
+}
+
+// invokeHandler calls Config.Handler, recovering a panic into an error
+// routed through ErrorHandler (with a stack trace) so a buggy handler
+// can't crash the poll loop; a recovered panic is treated as ErrRetry.
+func (r Retrier) invokeHandler(ctx context.Context, message Message) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			r.config.ErrorHandler(errors.Errorf("handler panicked: %v\n%s", p, debug.Stack()))
+			err = ErrRetry
 		}
+	}()
+	return r.config.Handler(ctx, message)
+}
+
+// queueDelaySeconds clamps delay to the range SQS accepts for DelaySeconds
+// on a send. A backoff longer than MaxQueueDelaySeconds is sent at the cap
+// instead: the message comes back due too early, computeMessageDelay's
+// additionalDelay check sees it isn't actually time yet and requeues it
+// again without invoking Handler, so the message is simply resent at the
+// cap over and over until the real backoff elapses.
+func queueDelaySeconds(delay time.Duration) int64 {
+	switch seconds := int64(delay.Seconds()); {
+	case seconds < 0:
+		return 0
+	case seconds > MaxQueueDelaySeconds:
+		return MaxQueueDelaySeconds
+	default:
+		return seconds
 	}
-	return r.sendToQueue(message)
 }
 
-func (r Retrier) sendToQueue(message message) error {
-	body, err := json.Marshal(message)
+func (r Retrier) sendToQueue(ctx context.Context, message Message) error {
+	body, err := r.config.codec().Marshal(message)
 	if err != nil {
-		return errors.Wrap(err, "failed to convert Message to JSON")
+		return errors.Wrap(err, "failed to encode message")
 	}
 
 	delay := message.NextAttempt.Sub(r.time.Now())
 
-	input := &sqs.SendMessageInput{
-		MessageBody:  aws.String(string(body)),
-		QueueUrl:     aws.String(r.config.QueueURL),
-		DelaySeconds: aws.Int64(int64(delay.Seconds())),
-	}
-
-	_, err = r.sqs.SendMessage(input)
+	err = r.sqs.SendMessage(ctx, SendMessageInput{
+		QueueURL:     r.config.QueueURL,
+		Body:         string(body),
+		DelaySeconds: queueDelaySeconds(delay),
+	})
 	if err != nil {
 		return errors.Wrap(err, "failed to send job to SQS")
 	}
@@ -135,71 +423,278 @@ func (r Retrier) sendToQueue(message message) error {
 	return nil
 }
 
-func (r Retrier) poll() {
+func (r Retrier) poll(ctx context.Context) {
+	defer close(r.done)
 	for {
-		r.pollOnce()
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		r.pollOnce(ctx)
 	}
 }
 
-func (r Retrier) pollOnce() {
-	params := &sqs.ReceiveMessageInput{
-		QueueUrl:        aws.String(r.config.QueueURL),
-		WaitTimeSeconds: aws.Int64(10),
-	}
-	output, err := r.sqs.ReceiveMessage(params)
+// pollOnce receives up to Config.BatchSize messages in a single long poll
+// and fans them out to a bounded pool of Config.MaxConcurrency workers.
+// Once every message in the batch has been handled, the resulting deletes
+// and requeues are each coalesced into a single DeleteMessageBatch /
+// SendMessageBatch call.
+func (r Retrier) pollOnce(ctx context.Context) {
+	output, err := r.sqs.ReceiveMessage(ctx, ReceiveMessageInput{
+		QueueURL:            r.config.QueueURL,
+		WaitTimeSeconds:     10,
+		MaxNumberOfMessages: r.config.receiveBatchSize(),
+	})
 	if err != nil {
+		if ctx.Err() != nil {
+			// Shutting down; the receive was aborted on purpose.
+			return
+		}
 		err = errors.Wrap(err, "failed to retrieve SQS message")
 		r.config.ErrorHandler(err)
 		return
 	}
-	if len(output.Messages) != 1 {
+	if len(output.Messages) == 0 {
 		return
 	}
 
-	sqsMessage := output.Messages[0]
-	if sqsMessage.Body == nil {
+	var (
+		mu        sync.Mutex
+		toDelete  []RawMessage
+		toRequeue []Message
+		toExtend  []visibilityExtension
+		sem       = make(chan struct{}, r.config.concurrency())
+		wg        sync.WaitGroup
+	)
+	for _, rawMessage := range output.Messages {
+		rawMessage := rawMessage
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			outcome := r.receiveMessage(ctx, rawMessage)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if outcome.delete {
+				toDelete = append(toDelete, rawMessage)
+			}
+			if outcome.requeue != nil {
+				toRequeue = append(toRequeue, *outcome.requeue)
+			}
+			if outcome.extend != nil {
+				toExtend = append(toExtend, visibilityExtension{
+					receiptHandle:  rawMessage.ReceiptHandle,
+					timeoutSeconds: r.visibilityTimeoutFor(*outcome.extend),
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(toRequeue) > 0 {
+		r.requeueMessages(ctx, toRequeue)
+	}
+	if len(toDelete) > 0 {
+		r.deleteMessages(ctx, toDelete)
+	}
+	if len(toExtend) > 0 {
+		r.extendVisibility(ctx, toExtend)
+	}
+}
+
+// messageOutcome describes what pollOnce should do with a message once
+// it's been worked: delete the original (on handlerDone, and alongside a
+// requeue under SendAndDeleteMode; not on handlerAbort or on exhausting
+// MaxAttempts, both of which leave it undeleted for the DLQ instead), send
+// a new message carrying updated state (SendAndDeleteMode
+// only, when the job isn't done), or extend the original's visibility
+// timeout in place (VisibilityTimeoutMode only).
+type messageOutcome struct {
+	delete  bool
+	requeue *Message
+	extend  *Message
+}
+
+// receiveMessage unmarshals and works a single message received from SQS.
+func (r Retrier) receiveMessage(ctx context.Context, rawMessage RawMessage) messageOutcome {
+	if rawMessage.Body == "" {
 		r.config.ErrorHandler(errors.New("The message retreived from SQS has no body"))
-		return
+		return messageOutcome{}
 	}
-	var message message
-	err = json.Unmarshal([]byte(*sqsMessage.Body), &message)
-	if err != nil {
-		err = errors.Wrap(err, "failed to read SQS message as JSON")
-		r.config.ErrorHandler(err)
-		return
+	var message Message
+	if err := r.config.codec().Unmarshal([]byte(rawMessage.Body), &message); err != nil {
+		r.config.ErrorHandler(errors.Wrap(err, "failed to decode SQS message"))
+		return messageOutcome{}
 	}
 
+	visibilityMode := r.config.RetryMode == VisibilityTimeoutMode
+	messageID := rawMessage.MessageID
+	if visibilityMode {
+		// The message body is whatever we last sent, which under
+		// VisibilityTimeoutMode is only ever its very first send; the
+		// store holds whatever progress we've made across attempts since.
+		message = r.visibility.load(messageID, message)
+	}
+	message.ReceiptHandle = rawMessage.ReceiptHandle
+	message.MessageAttributes = rawMessage.MessageAttributes
+
 	if r.config.MaxAttempts != 0 && int(message.AttemptedCount) >= r.config.MaxAttempts {
 		// We're just not going to process it which will put it in the DLQ
 		// Maybe just calling the ErrorHandler is better though?
 		// If someone doesn't have a DLQ set up, then these messages will exist forever
+		//
+		// Under visibilityMode the store entry has to survive this: it's the
+		// only record of the message's true AttemptedCount, and deleting it
+		// would make the next redelivery fall back to whatever was encoded
+		// on the original send, un-exhausting MaxAttempts.
+		return messageOutcome{}
+	}
+
+	result, outcome := r.workMessage(ctx, message)
+	switch outcome {
+	case handlerDone:
+		if visibilityMode {
+			r.visibility.delete(messageID)
+		}
+		return messageOutcome{delete: true}
+	case handlerAbort:
+		// Leave the original message alone so SQS's own redrive policy is
+		// what sends it to the DLQ. Under visibilityMode, pin the
+		// AttemptedCount this abort was decided at: if MaxAttempts applies,
+		// that's what lets the check above recognize a later redelivery
+		// (SQS will keep redelivering it same as SendAndDeleteMode would)
+		// as already exhausted instead of reprocessing it from scratch.
+		if visibilityMode {
+			r.visibility.save(messageID, result)
+		}
+		return messageOutcome{}
+	default: // handlerRetry
+		if visibilityMode {
+			r.visibility.save(messageID, result)
+			return messageOutcome{extend: &result}
+		}
+		return messageOutcome{delete: true, requeue: &result}
+	}
+}
+
+// requeueMessages sends every message in a single SendMessageBatch call.
+// len(messages) is always <= Config.BatchSize <= maxBatchSize, since they
+// all came out of one ReceiveMessage call.
+func (r Retrier) requeueMessages(ctx context.Context, messages []Message) {
+	entries := make([]SendMessageBatchEntry, 0, len(messages))
+	for i, message := range messages {
+		body, err := r.config.codec().Marshal(message)
+		if err != nil {
+			r.config.ErrorHandler(errors.Wrap(err, "failed to encode message"))
+			continue
+		}
+		delay := message.NextAttempt.Sub(r.time.Now())
+		entries = append(entries, SendMessageBatchEntry{
+			ID:           strconv.Itoa(i),
+			Body:         string(body),
+			DelaySeconds: queueDelaySeconds(delay),
+		})
+	}
+	if len(entries) == 0 {
 		return
 	}
 
-	err = r.workMessage(message)
+	failed, err := r.sqs.SendMessageBatch(ctx, SendMessageBatchInput{
+		QueueURL: r.config.QueueURL,
+		Entries:  entries,
+	})
 	if err != nil {
-		r.config.ErrorHandler(err)
+		r.config.ErrorHandler(errors.Wrap(err, "failed to send job batch to SQS"))
+		return
+	}
+	for _, failure := range failed {
+		r.config.ErrorHandler(errors.Errorf("failed to send job to SQS: %s", failure.Message))
 	}
+}
 
-	// Delete the SQS message
-	// Any return that happens prior to this point will put the message in DLQ
-	if _, err := r.deleteMessage(sqsMessage); err != nil {
-		err = errors.Wrap(err, "failed to delete SQS message")
-		r.config.ErrorHandler(err)
+// deleteMessages deletes every message in a single DeleteMessageBatch call.
+// len(messages) is always <= Config.BatchSize <= maxBatchSize, since they
+// all came out of one ReceiveMessage call.
+func (r Retrier) deleteMessages(ctx context.Context, messages []RawMessage) {
+	entries := make([]DeleteMessageBatchEntry, len(messages))
+	for i, rawMessage := range messages {
+		entries[i] = DeleteMessageBatchEntry{
+			ID:            strconv.Itoa(i),
+			ReceiptHandle: rawMessage.ReceiptHandle,
+		}
+	}
+
+	failed, err := r.sqs.DeleteMessageBatch(ctx, DeleteMessageBatchInput{
+		QueueURL: r.config.QueueURL,
+		Entries:  entries,
+	})
+	if err != nil {
+		r.config.ErrorHandler(errors.Wrap(err, "failed to delete SQS message batch"))
 		return
 	}
+	for _, failure := range failed {
+		r.config.ErrorHandler(errors.Errorf("failed to delete SQS message: %s", failure.Message))
+	}
+}
+
+// visibilityTimeoutFor clamps the delay until msg's next attempt to the
+// range SQS accepts for a single ChangeMessageVisibility call. A delay
+// longer than maxVisibilityTimeoutSeconds is handled by repeatedly
+// extending the visibility timeout a chunk at a time: the message becomes
+// visible again and gets redelivered once the current chunk elapses, at
+// which point computeMessageDelay sees it's still not due and we extend
+// it again for a fresh maxVisibilityTimeoutSeconds window.
+func (r Retrier) visibilityTimeoutFor(msg Message) int64 {
+	seconds := int64(msg.NextAttempt.Sub(r.time.Now()).Seconds())
+	switch {
+	case seconds < 0:
+		return 0
+	case seconds > maxVisibilityTimeoutSeconds:
+		return maxVisibilityTimeoutSeconds
+	default:
+		return seconds
+	}
 }
 
-func (r Retrier) deleteMessage(message *sqs.Message) (*sqs.DeleteMessageOutput, error) {
+// visibilityExtension pairs a received message's receipt handle with how
+// long its visibility timeout should be extended by.
+type visibilityExtension struct {
+	receiptHandle  string
+	timeoutSeconds int64
+}
+
+// extendVisibility changes the visibility timeout of every message in a
+// single ChangeMessageVisibilityBatch call. len(items) is always <=
+// Config.BatchSize <= maxBatchSize, since they all came out of one
+// ReceiveMessage call.
+func (r Retrier) extendVisibility(ctx context.Context, items []visibilityExtension) {
+	entries := make([]ChangeMessageVisibilityBatchEntry, len(items))
+	for i, item := range items {
+		entries[i] = ChangeMessageVisibilityBatchEntry{
+			ID:                strconv.Itoa(i),
+			ReceiptHandle:     item.receiptHandle,
+			VisibilityTimeout: item.timeoutSeconds,
+		}
+	}
 
-	params := &sqs.DeleteMessageInput{
-		QueueUrl:      aws.String(r.config.QueueURL),
-		ReceiptHandle: message.ReceiptHandle,
+	failed, err := r.sqs.ChangeMessageVisibilityBatch(ctx, ChangeMessageVisibilityBatchInput{
+		QueueURL: r.config.QueueURL,
+		Entries:  entries,
+	})
+	if err != nil {
+		r.config.ErrorHandler(errors.Wrap(err, "failed to change SQS message visibility batch"))
+		return
+	}
+	for _, failure := range failed {
+		r.config.ErrorHandler(errors.Errorf("failed to change SQS message visibility: %s", failure.Message))
 	}
-	return r.sqs.DeleteMessage(params)
 }
 
-func (r Retrier) computeMessageDelay(message message) (message, bool) {
+func (r Retrier) computeMessageDelay(message Message) (Message, bool) {
 	// If the item needs to be delayed more, even though we
 	// aren't yet to the next backoff iteration.
 	// We need this check since we are limited in how much
@@ -216,6 +711,6 @@ func (r Retrier) computeMessageDelay(message message) (message, bool) {
 	// We are actually proceeding to the next iteration of backoff...
 	message.AttemptedCount++
 	delay := r.config.BackoffStrategy(message.AttemptedCount)
-	message.NextAttempt = message.NextAttempt.Add(time.Duration(delay) * time.Second)
+	message.NextAttempt = message.NextAttempt.Add(delay)
 	return message, false
 }
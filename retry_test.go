@@ -1,6 +1,10 @@
 package retry
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
 	"testing"
 	"time"
 )
@@ -116,7 +120,7 @@ func TestRetry(t *testing.T) {
 				ErrorHandler: func(err error) {
 					t.Error(err)
 				},
-				Handler: mockFunc.invoke,
+				Handler: AdaptLegacyHandler(mockFunc.invoke),
 			},
 			sqs:  mockSQS,
 			time: clock,
@@ -129,7 +133,7 @@ func TestRetry(t *testing.T) {
 		}
 
 		for i := 0; i < test.numberOfPolls; i++ {
-			retrier.pollOnce()
+			retrier.pollOnce(context.Background())
 		}
 
 		if got, want := mockFunc.InvokedCount, test.expectHandlerInvokedCount; got != want {
@@ -153,3 +157,510 @@ func TestRetry(t *testing.T) {
 		}
 	}
 }
+
+// TestConcurrentBatching verifies that a single pollOnce receives a whole
+// batch of messages at once, works them concurrently, and coalesces the
+// resulting deletes into one DeleteMessageBatch call rather than one API
+// call per message.
+func TestConcurrentBatching(t *testing.T) {
+	const numJobs = 5
+
+	// completes every message the second time its ID is seen: once when
+	// Job enqueues it, once when pollOnce picks it back up.
+	var mu sync.Mutex
+	attempts := make(map[int]int)
+	handler := func(msg Message) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[msg.ID]++
+		return attempts[msg.ID] >= 2
+	}
+
+	clock := &mockClock{}
+	mockSQS := NewMockSQS(clock)
+	retrier := Retrier{
+		config: Config{
+			BackoffStrategy: LinearBackoff(0),
+			MaxAttempts:     2,
+			MaxConcurrency:  3,
+			BatchSize:       numJobs,
+			ErrorHandler: func(err error) {
+				t.Error(err)
+			},
+			Handler: AdaptLegacyHandler(handler),
+		},
+		sqs:  mockSQS,
+		time: clock,
+	}
+
+	for id := 0; id < numJobs; id++ {
+		if err := retrier.Job(id); err != nil {
+			t.Fatalf("unexpected error enqueueing job %d: %v", id, err)
+		}
+	}
+	if got, want := mockSQS.SendMessageInvokedCount, numJobs; got != want {
+		t.Fatalf("expected every job to be enqueued, expected=%d actual=%d", want, got)
+	}
+
+	retrier.pollOnce(context.Background())
+
+	if got, want := len(attempts), numJobs; got != want {
+		t.Errorf("handler invoked for incorrect number of distinct jobs, expected=%d actual=%d", want, got)
+	}
+	if got, want := mockSQS.ReceiveMessageInvokedCount, 1; got != want {
+		t.Errorf("SQS ReceiveMessage invoked incorrect number of times, expected=%d actual=%d", want, got)
+	}
+	if got, want := mockSQS.DeleteMessageInvokedCount, numJobs; got != want {
+		t.Errorf("SQS messages deleted incorrect number of times, expected=%d actual=%d", want, got)
+	}
+	if got, want := mockSQS.DeleteMessageBatchCallCount, 1; got != want {
+		t.Errorf("DeleteMessageBatch should have been called once to coalesce all deletes, expected=%d actual=%d", want, got)
+	}
+	if got, want := len(mockSQS.storage), 0; got != want {
+		t.Errorf("expected all messages to be removed from the queue, remaining=%d", got)
+	}
+}
+
+// TestVisibilityTimeoutMode verifies that under VisibilityTimeoutMode a
+// message that isn't done yet is retried by extending its visibility
+// timeout in place, rather than by the usual send-a-new-message-and-
+// delete-the-old-one churn, and that it's still deleted once the handler
+// reports it's done.
+func TestVisibilityTimeoutMode(t *testing.T) {
+	var attemptedCount int
+	handler := func(msg Message) bool {
+		attemptedCount++
+		return attemptedCount >= 3
+	}
+
+	clock := &mockClock{}
+	mockSQS := NewMockSQS(clock)
+	retrier := Retrier{
+		config: Config{
+			BackoffStrategy: LinearBackoff(0),
+			MaxAttempts:     5,
+			RetryMode:       VisibilityTimeoutMode,
+			ErrorHandler: func(err error) {
+				t.Error(err)
+			},
+			Handler: AdaptLegacyHandler(handler),
+		},
+		sqs:        mockSQS,
+		time:       clock,
+		visibility: newVisibilityStore(),
+	}
+
+	if err := retrier.Job(0); err != nil {
+		t.Fatalf("unexpected error enqueueing job: %v", err)
+	}
+	if got, want := mockSQS.SendMessageInvokedCount, 1; got != want {
+		t.Fatalf("expected job to be enqueued once, expected=%d actual=%d", want, got)
+	}
+
+	// The handler isn't done yet on its second invocation, so the message
+	// should stay in the queue with its visibility timeout extended
+	// instead of being resent.
+	retrier.pollOnce(context.Background())
+	if got, want := attemptedCount, 2; got != want {
+		t.Errorf("handler invoked incorrect number of times, expected=%d actual=%d", want, got)
+	}
+	if got, want := mockSQS.ChangeMessageVisibilityInvokedCount, 1; got != want {
+		t.Errorf("ChangeMessageVisibility invoked incorrect number of times, expected=%d actual=%d", want, got)
+	}
+	if got, want := mockSQS.SendMessageInvokedCount, 1; got != want {
+		t.Errorf("expected no additional SendMessage calls, expected=%d actual=%d", want, got)
+	}
+	if got, want := len(mockSQS.storage), 1; got != want {
+		t.Errorf("expected the message to remain in the queue, remaining=%d", got)
+	}
+
+	// The handler finishes on its third invocation: the message should be
+	// deleted and its visibility-store entry cleared.
+	retrier.pollOnce(context.Background())
+	if got, want := attemptedCount, 3; got != want {
+		t.Errorf("handler invoked incorrect number of times, expected=%d actual=%d", want, got)
+	}
+	if got, want := mockSQS.DeleteMessageInvokedCount, 1; got != want {
+		t.Errorf("SQS DeleteMessage invoked incorrect number of times, expected=%d actual=%d", want, got)
+	}
+	if got, want := mockSQS.SendMessageInvokedCount, 1; got != want {
+		t.Errorf("expected no additional SendMessage calls, expected=%d actual=%d", want, got)
+	}
+	if got, want := len(mockSQS.storage), 0; got != want {
+		t.Errorf("expected the message to be removed from the queue, remaining=%d", got)
+	}
+}
+
+// TestVisibilityTimeoutModeMaxAttemptsExceeded verifies that once a
+// message's AttemptedCount reaches MaxAttempts under VisibilityTimeoutMode,
+// Handler stops being invoked for good, even across further redeliveries
+// whose SQS body still carries the original (stale) AttemptedCount. This
+// requires the visibility-store entry to survive exhausting MaxAttempts,
+// since that's the only place the true AttemptedCount is tracked.
+func TestVisibilityTimeoutModeMaxAttemptsExceeded(t *testing.T) {
+	var attemptedCount int
+	handler := func(context.Context, Message) error {
+		attemptedCount++
+		return ErrRetry
+	}
+
+	clock := &mockClock{}
+	mockSQS := NewMockSQS(clock)
+	retrier := Retrier{
+		config: Config{
+			BackoffStrategy: LinearBackoff(0),
+			MaxAttempts:     3,
+			RetryMode:       VisibilityTimeoutMode,
+			ErrorHandler: func(err error) {
+				t.Error(err)
+			},
+			Handler: handler,
+		},
+		sqs:        mockSQS,
+		time:       clock,
+		visibility: newVisibilityStore(),
+	}
+
+	if err := retrier.Job(0); err != nil {
+		t.Fatalf("unexpected error enqueueing job: %v", err)
+	}
+
+	// Job's attempt plus two polls exhaust MaxAttempts; the third and fourth
+	// polls notice it's exhausted and leave the message alone instead of
+	// invoking Handler again.
+	for i := 0; i < 4; i++ {
+		retrier.pollOnce(context.Background())
+	}
+	if got, want := attemptedCount, 3; got != want {
+		t.Errorf("handler invoked incorrect number of times, expected=%d actual=%d", want, got)
+	}
+
+	// Further redeliveries must keep skipping Handler, not fall back to the
+	// original send's stale AttemptedCount and un-exhaust MaxAttempts.
+	for i := 0; i < 3; i++ {
+		retrier.pollOnce(context.Background())
+	}
+	if got, want := attemptedCount, 3; got != want {
+		t.Errorf("handler invoked after MaxAttempts was exhausted, expected=%d actual=%d", want, got)
+	}
+	if got, want := len(mockSQS.storage), 1; got != want {
+		t.Errorf("expected the exhausted message to remain in the queue for SQS's own redrive policy, remaining=%d", got)
+	}
+}
+
+// TestVisibilityTimeoutModeErrAbort verifies that ErrAbort is terminal
+// under VisibilityTimeoutMode: once the AttemptedCount it was decided at
+// reaches MaxAttempts, Handler must not be invoked again on later
+// redeliveries of the same message.
+func TestVisibilityTimeoutModeErrAbort(t *testing.T) {
+	var attemptedCount int
+	handler := func(context.Context, Message) error {
+		attemptedCount++
+		if attemptedCount < 2 {
+			return ErrRetry
+		}
+		return ErrAbort
+	}
+
+	clock := &mockClock{}
+	mockSQS := NewMockSQS(clock)
+	retrier := Retrier{
+		config: Config{
+			BackoffStrategy: LinearBackoff(0),
+			MaxAttempts:     2,
+			RetryMode:       VisibilityTimeoutMode,
+			ErrorHandler: func(err error) {
+				t.Error(err)
+			},
+			Handler: handler,
+		},
+		sqs:        mockSQS,
+		time:       clock,
+		visibility: newVisibilityStore(),
+	}
+
+	// The first attempt, made directly by Job, isn't done yet, so the
+	// message is queued; the second (this time out of pollOnce) aborts.
+	if err := retrier.Job(0); err != nil {
+		t.Fatalf("unexpected error enqueueing job: %v", err)
+	}
+	retrier.pollOnce(context.Background())
+	if got, want := attemptedCount, 2; got != want {
+		t.Fatalf("handler invoked incorrect number of times, expected=%d actual=%d", want, got)
+	}
+
+	// Further redeliveries must keep skipping Handler, not fall back to a
+	// stale AttemptedCount and un-exhaust MaxAttempts.
+	for i := 0; i < 3; i++ {
+		retrier.pollOnce(context.Background())
+	}
+	if got, want := attemptedCount, 2; got != want {
+		t.Errorf("handler invoked after aborting, expected=%d actual=%d", want, got)
+	}
+	if got, want := len(mockSQS.storage), 1; got != want {
+		t.Errorf("expected the aborted message to remain in the queue for SQS's own redrive policy, remaining=%d", got)
+	}
+}
+
+type testCtxKey struct{}
+
+// TestHandlerReceivesContextAndMetadata verifies that a message received
+// off the queue carries the poll's context and is populated with the
+// metadata (ReceiptHandle, NextAttempt) Handler needs to
+// make decisions, not just the bare ID/AttemptedCount a new Job carries.
+func TestHandlerReceivesContextAndMetadata(t *testing.T) {
+	var calls int
+	var gotCtx context.Context
+	var gotMsg Message
+	handler := func(ctx context.Context, msg Message) error {
+		calls++
+		if calls == 1 {
+			// The first invocation happens inside Job, before the message
+			// has ever been through SQS; only the second, polled-back
+			// invocation is interesting here.
+			return ErrRetry
+		}
+		gotCtx = ctx
+		gotMsg = msg
+		return nil
+	}
+
+	clock := &mockClock{time: time.Unix(1000, 0)}
+	mockSQS := NewMockSQS(clock)
+	retrier := Retrier{
+		config: Config{
+			BackoffStrategy: LinearBackoff(0),
+			MaxAttempts:     5,
+			ErrorHandler: func(err error) {
+				t.Error(err)
+			},
+			Handler: handler,
+		},
+		sqs:  mockSQS,
+		time: clock,
+	}
+
+	if err := retrier.Job(7); err != nil {
+		t.Fatalf("unexpected error enqueueing job: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), testCtxKey{}, "marker")
+	retrier.pollOnce(ctx)
+
+	if calls != 2 {
+		t.Fatalf("expected handler to be invoked twice, got %d", calls)
+	}
+	if got, want := gotCtx.Value(testCtxKey{}), "marker"; got != want {
+		t.Errorf("expected the poll's context to reach Handler, got=%v want=%v", got, want)
+	}
+	if got, want := gotMsg.ID, 7; got != want {
+		t.Errorf("expected message ID to round-trip, got=%d want=%d", got, want)
+	}
+	if gotMsg.ReceiptHandle == "" {
+		t.Error("expected ReceiptHandle to be populated from the SQS message")
+	}
+	if !gotMsg.NextAttempt.Equal(clock.time) {
+		t.Errorf("expected NextAttempt to carry through from the original job, got=%s want=%s", gotMsg.NextAttempt, clock.time)
+	}
+}
+
+// TestErrAbort verifies that a handler returning ErrAbort leaves the
+// message undeleted and unrequeued, for SQS's own redrive policy to send
+// to the DLQ, regardless of Config.MaxAttempts.
+func TestErrAbort(t *testing.T) {
+	var calls int
+	handler := func(ctx context.Context, msg Message) error {
+		calls++
+		if calls == 1 {
+			return ErrRetry
+		}
+		return ErrAbort
+	}
+
+	clock := &mockClock{}
+	mockSQS := NewMockSQS(clock)
+	retrier := Retrier{
+		config: Config{
+			BackoffStrategy: LinearBackoff(0),
+			MaxAttempts:     5,
+			ErrorHandler: func(err error) {
+				t.Error(err)
+			},
+			Handler: handler,
+		},
+		sqs:  mockSQS,
+		time: clock,
+	}
+
+	if err := retrier.Job(0); err != nil {
+		t.Fatalf("unexpected error enqueueing job: %v", err)
+	}
+
+	retrier.pollOnce(context.Background())
+
+	if got, want := calls, 2; got != want {
+		t.Fatalf("expected handler to be invoked twice, got=%d want=%d", got, want)
+	}
+	if got, want := mockSQS.DeleteMessageInvokedCount, 0; got != want {
+		t.Errorf("expected ErrAbort to leave the message undeleted, deletes=%d", got)
+	}
+	if got, want := mockSQS.SendMessageInvokedCount, 1; got != want {
+		t.Errorf("expected ErrAbort not to requeue the message, sends=%d", got)
+	}
+	if got, want := len(mockSQS.storage), 1; got != want {
+		t.Errorf("expected the message to remain in the queue for SQS's own redrive policy, remaining=%d", got)
+	}
+}
+
+// TestHandlerPanicRecovered verifies that a panic inside Handler is
+// recovered, reported to ErrorHandler, and treated as a normal retry
+// rather than crashing the poll loop.
+func TestHandlerPanicRecovered(t *testing.T) {
+	var calls int
+	handler := func(ctx context.Context, msg Message) error {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		return nil
+	}
+
+	var reported []error
+	clock := &mockClock{}
+	mockSQS := NewMockSQS(clock)
+	retrier := Retrier{
+		config: Config{
+			BackoffStrategy: LinearBackoff(0),
+			MaxAttempts:     5,
+			ErrorHandler: func(err error) {
+				reported = append(reported, err)
+			},
+			Handler: handler,
+		},
+		sqs:  mockSQS,
+		time: clock,
+	}
+
+	if err := retrier.Job(0); err != nil {
+		t.Fatalf("unexpected error enqueueing job: %v", err)
+	}
+
+	retrier.pollOnce(context.Background())
+
+	if got, want := calls, 2; got != want {
+		t.Fatalf("expected handler to be invoked twice, got=%d want=%d", got, want)
+	}
+	if got, want := len(reported), 1; got != want {
+		t.Fatalf("expected exactly one panic to be reported, got=%d want=%d", got, want)
+	}
+	if got, want := mockSQS.DeleteMessageInvokedCount, 1; got != want {
+		t.Errorf("expected the message to be deleted once the handler succeeds on retry, deletes=%d", got)
+	}
+}
+
+// TestPayloadSurvivesRetryCycle verifies that a message's Payload rides
+// along through the codec unchanged across a full send/receive cycle,
+// rather than just being asserted against Config.codec() in isolation.
+func TestPayloadSurvivesRetryCycle(t *testing.T) {
+	var gotPayload []byte
+	handler := func(ctx context.Context, msg Message) error {
+		gotPayload = msg.Payload
+		return nil
+	}
+
+	clock := &mockClock{}
+	mockSQS := NewMockSQS(clock)
+	retrier := Retrier{
+		config: Config{
+			BackoffStrategy: LinearBackoff(0),
+			MaxAttempts:     5,
+			ErrorHandler: func(err error) {
+				t.Error(err)
+			},
+			Handler: handler,
+		},
+		sqs:  mockSQS,
+		time: clock,
+	}
+
+	want := []byte("hello world")
+	message := Message{
+		ID:           1,
+		ReceivedTime: clock.Now(),
+		NextAttempt:  clock.Now(),
+		Payload:      want,
+	}
+	if err := retrier.sendToQueue(context.Background(), message); err != nil {
+		t.Fatalf("unexpected error sending to queue: %v", err)
+	}
+
+	retrier.pollOnce(context.Background())
+
+	if !bytes.Equal(gotPayload, want) {
+		t.Errorf("expected Payload to survive a retry cycle, got=%q want=%q", gotPayload, want)
+	}
+}
+
+func TestStopWaitsForInFlightPoll(t *testing.T) {
+	clock := &mockClock{}
+	mockSQS := NewMockSQS(clock)
+
+	body, err := json.Marshal(Message{ID: 1, NextAttempt: clock.Now()})
+	if err != nil {
+		t.Fatalf("unexpected error encoding message: %v", err)
+	}
+	mockSQS.storage["key1"] = string(body)
+
+	inHandler := make(chan struct{})
+	unblock := make(chan struct{})
+	handler := func(context.Context, Message) error {
+		close(inHandler)
+		<-unblock
+		return nil
+	}
+
+	retrier, err := NewWithClient(context.Background(), mockSQS, Config{
+		ErrorHandler:    func(err error) {},
+		Handler:         handler,
+		BackoffStrategy: LinearBackoff(0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error from NewWithClient: %v", err)
+	}
+
+	// Wait for the poll loop to actually be blocked inside Handler before
+	// calling Stop, so Stop is guaranteed to have to wait on it.
+	<-inHandler
+
+	stopped := make(chan struct{})
+	go func() {
+		retrier.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned before the in-flight Handler call finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(unblock)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return after the in-flight Handler call finished")
+	}
+}
+
+func TestAdaptLegacyHandler(t *testing.T) {
+	done := AdaptLegacyHandler(func(Message) bool { return true })
+	if err := done(context.Background(), Message{}); err != nil {
+		t.Errorf("expected true to adapt to a nil error, got %v", err)
+	}
+
+	retry := AdaptLegacyHandler(func(Message) bool { return false })
+	if err := retry(context.Background(), Message{}); err != ErrRetry {
+		t.Errorf("expected false to adapt to ErrRetry, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+// Package sqsv1 adapts aws-sdk-go (v1) for use with github.com/stevenmatthewt/retry,
+// for callers who want to construct their own session (custom credentials,
+// a non-default endpoint, request retries, etc.) rather than going through
+// retry.New's built-in session handling.
+package sqsv1
+
+import (
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stevenmatthewt/retry"
+)
+
+// New builds a retry.Client backed by aws-sdk-go (v1) from sess. Pass the
+// result to retry.NewWithClient.
+func New(sess *session.Session) retry.Client {
+	return retry.NewV1Client(sqs.New(sess))
+}
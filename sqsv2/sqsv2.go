@@ -0,0 +1,185 @@
+// Package sqsv2 adapts aws-sdk-go-v2 for use with
+// github.com/stevenmatthewt/retry. It's a separate package so that
+// consumers who only need aws-sdk-go (v1), via retry.New or
+// github.com/stevenmatthewt/retry/sqsv1, don't have to pull in the v2 SDK
+// as well.
+package sqsv2
+
+import (
+	"context"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/pkg/errors"
+	"github.com/stevenmatthewt/retry"
+)
+
+// Config mirrors retry.Config for an aws-sdk-go-v2 SQS client. Client is
+// optional; if nil, New loads one via config.LoadDefaultConfig.
+type Config struct {
+	Client   *sqs.Client
+	QueueURL string
+
+	MaxAttempts     int
+	BackoffStrategy retry.BackoffFunc
+	ErrorHandler    retry.ErrorHandler
+	Handler         retry.ActionHandler
+
+	MaxConcurrency int
+	BatchSize      int
+	RetryMode      retry.RetryMode
+	Codec          retry.Codec
+}
+
+func (c Config) retryConfig() retry.Config {
+	return retry.Config{
+		QueueURL:        c.QueueURL,
+		MaxAttempts:     c.MaxAttempts,
+		BackoffStrategy: c.BackoffStrategy,
+		ErrorHandler:    c.ErrorHandler,
+		Handler:         c.Handler,
+		MaxConcurrency:  c.MaxConcurrency,
+		BatchSize:       c.BatchSize,
+		RetryMode:       c.RetryMode,
+		Codec:           c.Codec,
+	}
+}
+
+// New begins polling based on the provided Config, using aws-sdk-go-v2, and
+// ties the poll loop's lifetime to ctx. Call Stop (or cancel ctx) to shut
+// it down.
+func New(ctx context.Context, config Config) (*retry.Retrier, error) {
+	client := config.Client
+	if client == nil {
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load default AWS config")
+		}
+		client = sqs.NewFromConfig(cfg)
+	}
+	return retry.NewWithClient(ctx, newClient(client), config.retryConfig())
+}
+
+// newClient adapts an aws-sdk-go-v2 SQS client for use as a retry.Client.
+func newClient(api *sqs.Client) retry.Client {
+	return v2Client{api: api}
+}
+
+type v2Client struct {
+	api *sqs.Client
+}
+
+func (c v2Client) ReceiveMessage(ctx context.Context, in retry.ReceiveMessageInput) (retry.ReceiveMessageOutput, error) {
+	output, err := c.api.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              awssdk.String(in.QueueURL),
+		MaxNumberOfMessages:   int32(in.MaxNumberOfMessages),
+		WaitTimeSeconds:       int32(in.WaitTimeSeconds),
+		MessageAttributeNames: []string{"All"},
+	})
+	if err != nil {
+		return retry.ReceiveMessageOutput{}, err
+	}
+
+	messages := make([]retry.RawMessage, len(output.Messages))
+	for i, m := range output.Messages {
+		messages[i] = retry.RawMessage{
+			Body:              awssdk.ToString(m.Body),
+			MessageID:         awssdk.ToString(m.MessageId),
+			ReceiptHandle:     awssdk.ToString(m.ReceiptHandle),
+			MessageAttributes: messageAttributeValuesToMap(m.MessageAttributes),
+		}
+	}
+	return retry.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (c v2Client) SendMessage(ctx context.Context, in retry.SendMessageInput) error {
+	_, err := c.api.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:     awssdk.String(in.QueueURL),
+		MessageBody:  awssdk.String(in.Body),
+		DelaySeconds: int32(in.DelaySeconds),
+	})
+	return err
+}
+
+func (c v2Client) SendMessageBatch(ctx context.Context, in retry.SendMessageBatchInput) ([]retry.BatchResultError, error) {
+	entries := make([]types.SendMessageBatchRequestEntry, len(in.Entries))
+	for i, e := range in.Entries {
+		entries[i] = types.SendMessageBatchRequestEntry{
+			Id:           awssdk.String(e.ID),
+			MessageBody:  awssdk.String(e.Body),
+			DelaySeconds: int32(e.DelaySeconds),
+		}
+	}
+	output, err := c.api.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: awssdk.String(in.QueueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batchFailuresToErrors(output.Failed), nil
+}
+
+func (c v2Client) DeleteMessageBatch(ctx context.Context, in retry.DeleteMessageBatchInput) ([]retry.BatchResultError, error) {
+	entries := make([]types.DeleteMessageBatchRequestEntry, len(in.Entries))
+	for i, e := range in.Entries {
+		entries[i] = types.DeleteMessageBatchRequestEntry{
+			Id:            awssdk.String(e.ID),
+			ReceiptHandle: awssdk.String(e.ReceiptHandle),
+		}
+	}
+	output, err := c.api.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: awssdk.String(in.QueueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batchFailuresToErrors(output.Failed), nil
+}
+
+func (c v2Client) ChangeMessageVisibilityBatch(ctx context.Context, in retry.ChangeMessageVisibilityBatchInput) ([]retry.BatchResultError, error) {
+	entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, len(in.Entries))
+	for i, e := range in.Entries {
+		entries[i] = types.ChangeMessageVisibilityBatchRequestEntry{
+			Id:                awssdk.String(e.ID),
+			ReceiptHandle:     awssdk.String(e.ReceiptHandle),
+			VisibilityTimeout: int32(e.VisibilityTimeout),
+		}
+	}
+	output, err := c.api.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+		QueueUrl: awssdk.String(in.QueueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batchFailuresToErrors(output.Failed), nil
+}
+
+// messageAttributeValuesToMap reduces SQS's attribute value wrapper down to
+// a plain map of string values, since that covers the overwhelming common
+// case and is what Handler needs to make a decision.
+func messageAttributeValuesToMap(attrs map[string]types.MessageAttributeValue) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		out[k] = awssdk.ToString(v.StringValue)
+	}
+	return out
+}
+
+func batchFailuresToErrors(failed []types.BatchResultErrorEntry) []retry.BatchResultError {
+	if len(failed) == 0 {
+		return nil
+	}
+	out := make([]retry.BatchResultError, len(failed))
+	for i, f := range failed {
+		out[i] = retry.BatchResultError{ID: awssdk.ToString(f.Id), Message: awssdk.ToString(f.Message)}
+	}
+	return out
+}